@@ -0,0 +1,56 @@
+// Copyright © 2019 Weald Technology Trading
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+
+package keystorev4
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"errors"
+	"fmt"
+)
+
+// decryptCipher decrypts cipherMsg with decryptionKey and iv per the
+// cipher function named by fn.  It is shared by the EIP-2335 and Web3
+// Secret Storage v3 decrypt paths so that both keystore formats support
+// the same set of ciphers as Encrypt can produce.
+func decryptCipher(fn string, decryptionKey, iv, cipherMsg []byte) ([]byte, error) {
+	switch fn {
+	case "xor":
+		res := make([]byte, len(decryptionKey))
+		for i := range decryptionKey {
+			res[i] = decryptionKey[i] ^ cipherMsg[i]
+		}
+		return res, nil
+	case "aes-128-ctr":
+		aesCipher, err := aes.NewCipher(decryptionKey[:16])
+		if err != nil {
+			return nil, err
+		}
+		res := make([]byte, len(cipherMsg))
+		cipher.NewCTR(aesCipher, iv).XORKeyStream(res, cipherMsg)
+		return res, nil
+	case "aes-128-cbc":
+		aesCipher, err := aes.NewCipher(decryptionKey[:16])
+		if err != nil {
+			return nil, err
+		}
+		if len(cipherMsg) == 0 || len(cipherMsg)%aes.BlockSize != 0 {
+			return nil, errors.New("invalid cipher message length")
+		}
+		padded := make([]byte, len(cipherMsg))
+		cipher.NewCBCDecrypter(aesCipher, iv).CryptBlocks(padded, cipherMsg)
+		return pkcs7Unpad(padded, aes.BlockSize)
+	default:
+		return nil, fmt.Errorf("unsupported cipher %q", fn)
+	}
+}
@@ -0,0 +1,110 @@
+// Copyright © 2019 Weald Technology Trading
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+
+package keystorev4
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestEIP2335CBCRoundTrip(t *testing.T) {
+	secret := []byte("a secret that is not a multiple of the AES block size")
+
+	e := New(WithCipher("aes-128-cbc"))
+	ks, err := e.Encrypt(secret, "testpassword")
+	if err != nil {
+		t.Fatalf("encrypt failed: %v", err)
+	}
+
+	decrypted, err := e.Decrypt(ks, []byte("testpassword"))
+	if err != nil {
+		t.Fatalf("decrypt failed: %v", err)
+	}
+	if !bytes.Equal(decrypted, secret) {
+		t.Fatalf("decrypted secret does not match: got %q, expected %q", decrypted, secret)
+	}
+}
+
+// TestWeb3V3CBCRoundTrip is a regression test: Encrypt used to accept
+// WithCipher("aes-128-cbc") for a Web3 Secret Storage v3 keystore and
+// produce one that Decrypt could not then read back.
+func TestWeb3V3CBCRoundTrip(t *testing.T) {
+	secret := []byte("another secret of arbitrary length")
+
+	e := New(WithFormat(FormatWeb3V3), WithCipher("aes-128-cbc"))
+	ks, err := e.Encrypt(secret, "testpassword")
+	if err != nil {
+		t.Fatalf("encrypt failed: %v", err)
+	}
+
+	decrypted, err := e.Decrypt(ks, []byte("testpassword"))
+	if err != nil {
+		t.Fatalf("decrypt failed: %v", err)
+	}
+	if !bytes.Equal(decrypted, secret) {
+		t.Fatalf("decrypted secret does not match: got %q, expected %q", decrypted, secret)
+	}
+}
+
+func TestPKCS7UnpadRejectsCorruptPadding(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{
+			name: "zero padding length",
+			data: []byte{1, 2, 3, 4, 5, 6, 7, 0},
+		},
+		{
+			name: "padding length exceeds block size",
+			data: []byte{1, 2, 3, 4, 5, 6, 7, 17},
+		},
+		{
+			name: "inconsistent padding bytes",
+			data: []byte{1, 2, 3, 4, 5, 3, 2, 3},
+		},
+		{
+			name: "not a multiple of the block size",
+			data: []byte{1, 2, 3, 4, 5},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := pkcs7Unpad(test.data, 8); err == nil {
+				t.Fatal("expected corrupt padding to be rejected")
+			}
+		})
+	}
+}
+
+func TestPKCS7PadUnpadRoundTrip(t *testing.T) {
+	for _, data := range [][]byte{
+		[]byte(""),
+		[]byte("a"),
+		[]byte("exactly16bytes!!"),
+		[]byte("a secret that is not a multiple of the AES block size"),
+	} {
+		padded := pkcs7Pad(data, 16)
+		if len(padded)%16 != 0 {
+			t.Fatalf("padded length %d is not a multiple of the block size", len(padded))
+		}
+		unpadded, err := pkcs7Unpad(padded, 16)
+		if err != nil {
+			t.Fatalf("unpad failed: %v", err)
+		}
+		if !bytes.Equal(unpadded, data) {
+			t.Fatalf("round trip mismatch: got %q, expected %q", unpadded, data)
+		}
+	}
+}
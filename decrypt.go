@@ -13,33 +13,81 @@
 package keystorev4
 
 import (
-	"bytes"
-	"crypto/aes"
-	"crypto/cipher"
 	"crypto/sha256"
+	"crypto/subtle"
 	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"hash"
 
 	"golang.org/x/crypto/pbkdf2"
 	"golang.org/x/crypto/scrypt"
+	"golang.org/x/crypto/sha3"
 )
 
 // Decrypt decrypts the data provided, returning the secret.
 func (e *Encryptor) Decrypt(data map[string]interface{}, passphrase []byte) ([]byte, error) {
-	// Sanity checks
+	if isWeb3V3(data) {
+		return e.decryptWeb3V3(data, passphrase)
+	}
+
+	ks, err := parseKeystoreV4(data)
+	if err != nil {
+		return nil, err
+	}
+
+	decryptionKey, err := deriveKey(ks, passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	cipherMsg, err := verifyChecksum(ks, decryptionKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return decryptPayload(ks, decryptionKey, cipherMsg)
+}
+
+// Verify checks that passphrase unlocks the keystore described by data,
+// without decrypting or returning the secret.  It is intended for
+// unlock UIs, rate-limited login flows and other "is this the right
+// password?" checks where the plaintext secret should never enter the
+// caller's memory.  Only EIP-2335 keystores are supported: for a Web3
+// Secret Storage v3 keystore Verify always returns an error.
+func (e *Encryptor) Verify(data map[string]interface{}, passphrase []byte) error {
+	if isWeb3V3(data) {
+		return errors.New("Verify does not support Web3 Secret Storage v3 keystores")
+	}
+
+	ks, err := parseKeystoreV4(data)
+	if err != nil {
+		return err
+	}
+
+	decryptionKey, err := deriveKey(ks, passphrase)
+	if err != nil {
+		return err
+	}
+
+	_, err = verifyChecksum(ks, decryptionKey)
+	return err
+}
+
+// parseKeystoreV4 unmarshals data as the "crypto" envelope of an
+// EIP-2335 keystore, checking that the fields required by deriveKey and
+// verifyChecksum are present.
+func parseKeystoreV4(data map[string]interface{}) (*keystoreV4, error) {
 	b, err := json.Marshal(data)
 	if err != nil {
 		return nil, errors.New("failed to parse keystore")
 	}
 	ks := &keystoreV4{}
-	err = json.Unmarshal(b, &ks)
-	if err != nil {
+	if err := json.Unmarshal(b, ks); err != nil {
 		return nil, errors.New("failed to parse keystore")
 	}
 
-	// Checksum and cipher are required
 	if ks.Checksum == nil {
 		return nil, errors.New("no checksum")
 	}
@@ -47,35 +95,46 @@ func (e *Encryptor) Decrypt(data map[string]interface{}, passphrase []byte) ([]b
 		return nil, errors.New("no cipher")
 	}
 
-	// Decryption key
-	var decryptionKey []byte
+	return ks, nil
+}
+
+// deriveKey runs ks's KDF over passphrase, returning the resulting
+// decryption key.
+func deriveKey(ks *keystoreV4, passphrase []byte) ([]byte, error) {
 	if ks.KDF == nil {
-		decryptionKey = passphrase
-	} else {
-		kdfParams := ks.KDF.Params
-		salt, err := hex.DecodeString(kdfParams.Salt)
-		if err != nil {
-			return nil, errors.New("invalid KDF salt")
-		}
-		switch ks.KDF.Function {
-		case "scrypt":
-			decryptionKey, err = scrypt.Key(passphrase, salt, kdfParams.N, kdfParams.R, kdfParams.P, kdfParams.DKLen)
-		case "pbkdf2":
-			switch kdfParams.PRF {
-			case "hmac-sha256":
-				decryptionKey = pbkdf2.Key(passphrase, salt, kdfParams.C, kdfParams.DKLen, sha256.New)
-			default:
-				return nil, fmt.Errorf("unsupported PBKDF2 PRF %q", kdfParams.PRF)
-			}
-		default:
-			return nil, fmt.Errorf("unsupported KDF %q", ks.KDF.Function)
-		}
+		return passphrase, nil
+	}
+
+	kdfParams := ks.KDF.Params
+	salt, err := hex.DecodeString(kdfParams.Salt)
+	if err != nil {
+		return nil, errors.New("invalid KDF salt")
+	}
+
+	var decryptionKey []byte
+	switch ks.KDF.Function {
+	case "scrypt":
+		decryptionKey, err = scrypt.Key(passphrase, salt, kdfParams.N, kdfParams.R, kdfParams.P, kdfParams.DKLen)
+	case "pbkdf2":
+		var hashFn func() hash.Hash
+		hashFn, err = pbkdf2Hash(kdfParams.PRF)
 		if err != nil {
-			return nil, errors.New("invalid KDF parameters")
+			return nil, err
 		}
+		decryptionKey = pbkdf2.Key(passphrase, salt, kdfParams.C, kdfParams.DKLen, hashFn)
+	default:
+		return nil, fmt.Errorf("unsupported KDF %q", ks.KDF.Function)
+	}
+	if err != nil {
+		return nil, errors.New("invalid KDF parameters")
 	}
 
-	// Checksum
+	return decryptionKey, nil
+}
+
+// verifyChecksum checks ks's checksum against decryptionKey in constant
+// time, returning the decoded cipher message for decryptPayload.
+func verifyChecksum(ks *keystoreV4, decryptionKey []byte) ([]byte, error) {
 	if len(decryptionKey) < 32 {
 		return nil, errors.New("decryption key must be at least 32 bytes")
 	}
@@ -83,6 +142,7 @@ func (e *Encryptor) Decrypt(data map[string]interface{}, passphrase []byte) ([]b
 	if err != nil {
 		return nil, errors.New("invalid cipher message")
 	}
+
 	h := sha256.New()
 	if _, err := h.Write(decryptionKey[16:32]); err != nil {
 		return nil, err
@@ -90,36 +150,92 @@ func (e *Encryptor) Decrypt(data map[string]interface{}, passphrase []byte) ([]b
 	if _, err := h.Write(cipherMsg); err != nil {
 		return nil, err
 	}
-	checksum := h.Sum(nil)
 	checksumMsg, err := hex.DecodeString(ks.Checksum.Message)
 	if err != nil {
 		return nil, errors.New("invalid checksum message")
 	}
-	if !bytes.Equal(checksum, checksumMsg) {
+	if subtle.ConstantTimeCompare(h.Sum(nil), checksumMsg) != 1 {
 		return nil, errors.New("invalid checksum")
 	}
 
-	// Decrypt
-	res := make([]byte, len(decryptionKey))
-	switch ks.Cipher.Function {
-	case "xor":
-		for i := range decryptionKey {
-			res[i] = decryptionKey[i] ^ cipherMsg[i]
-		}
-	case "aes-128-ctr":
-		aesCipher, err := aes.NewCipher(decryptionKey[:16])
+	return cipherMsg, nil
+}
+
+// decryptPayload applies ks's cipher to cipherMsg using decryptionKey,
+// returning the secret.
+func decryptPayload(ks *keystoreV4, decryptionKey, cipherMsg []byte) ([]byte, error) {
+	iv, err := hex.DecodeString(ks.Cipher.Params.IV)
+	if err != nil {
+		return nil, errors.New("invalid IV")
+	}
+
+	return decryptCipher(ks.Cipher.Function, decryptionKey, iv, cipherMsg)
+}
+
+// decryptWeb3V3 decrypts the data provided as a go-ethereum / Web3
+// Secret Storage v3 keystore, returning the secret.
+func (e *Encryptor) decryptWeb3V3(data map[string]interface{}, passphrase []byte) ([]byte, error) {
+	b, err := json.Marshal(data)
+	if err != nil {
+		return nil, errors.New("failed to parse keystore")
+	}
+	ks := &web3V3Keystore{}
+	if err := json.Unmarshal(b, ks); err != nil {
+		return nil, errors.New("failed to parse keystore")
+	}
+
+	salt, err := hex.DecodeString(ks.KDFParams.Salt)
+	if err != nil {
+		return nil, errors.New("invalid KDF salt")
+	}
+
+	var decryptionKey []byte
+	switch ks.KDF {
+	case "scrypt":
+		decryptionKey, err = scrypt.Key(passphrase, salt, ks.KDFParams.N, ks.KDFParams.R, ks.KDFParams.P, ks.KDFParams.DKLen)
+	case "pbkdf2":
+		var hashFn func() hash.Hash
+		hashFn, err = pbkdf2Hash(ks.KDFParams.PRF)
 		if err != nil {
 			return nil, err
 		}
-		iv, err := hex.DecodeString(ks.Cipher.Params.IV)
-		if err != nil {
-			return nil, errors.New("invalid IV")
-		}
-		stream := cipher.NewCTR(aesCipher, iv)
-		stream.XORKeyStream(res, cipherMsg)
+		decryptionKey = pbkdf2.Key(passphrase, salt, ks.KDFParams.C, ks.KDFParams.DKLen, hashFn)
 	default:
-		return nil, fmt.Errorf("unsupported cipher %q", ks.Cipher.Function)
+		return nil, fmt.Errorf("unsupported KDF %q", ks.KDF)
+	}
+	if err != nil {
+		return nil, errors.New("invalid KDF parameters")
+	}
+	if len(decryptionKey) < 32 {
+		return nil, errors.New("decryption key must be at least 32 bytes")
+	}
+
+	cipherMsg, err := hex.DecodeString(ks.Ciphertext)
+	if err != nil {
+		return nil, errors.New("invalid cipher message")
+	}
+	mac, err := hex.DecodeString(ks.MAC)
+	if err != nil {
+		return nil, errors.New("invalid mac")
+	}
+
+	// Web3 Secret Storage v3 uses Keccak256, not SHA3-256 or SHA-256, for
+	// its integrity tag.
+	h := sha3.NewLegacyKeccak256()
+	if _, err := h.Write(decryptionKey[16:32]); err != nil {
+		return nil, err
+	}
+	if _, err := h.Write(cipherMsg); err != nil {
+		return nil, err
+	}
+	if subtle.ConstantTimeCompare(h.Sum(nil), mac) != 1 {
+		return nil, errors.New("invalid mac")
+	}
+
+	iv, err := hex.DecodeString(ks.CipherParams.IV)
+	if err != nil {
+		return nil, errors.New("invalid IV")
 	}
 
-	return res, nil
-}
\ No newline at end of file
+	return decryptCipher(ks.Cipher, decryptionKey, iv, cipherMsg)
+}
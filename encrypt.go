@@ -0,0 +1,192 @@
+// Copyright © 2019 Weald Technology Trading
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+
+package keystorev4
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"golang.org/x/crypto/pbkdf2"
+	"golang.org/x/crypto/scrypt"
+	"golang.org/x/crypto/sha3"
+)
+
+// Encrypt encrypts the data provided, returning the JSON "crypto"
+// envelope of a keystore in the format selected by WithFormat (EIP-2335
+// by default), using the KDF selected by WithKDF/WithKDFParams
+// (ScryptStandard by default).
+func (e *Encryptor) Encrypt(secret []byte, passphrase string) (map[string]interface{}, error) {
+	if len(secret) == 0 {
+		return nil, errors.New("no secret")
+	}
+	if err := validateKDFParams(e.kdf, e.kdfParams); err != nil {
+		return nil, err
+	}
+
+	decryptionKey, kdfName, kdfParamsMsg, err := e.deriveEncryptionKey([]byte(passphrase))
+	if err != nil {
+		return nil, err
+	}
+
+	iv := make([]byte, 16)
+	if _, err := rand.Read(iv); err != nil {
+		return nil, err
+	}
+
+	cipherMsg, err := e.encryptPayload(secret, decryptionKey, iv)
+	if err != nil {
+		return nil, err
+	}
+
+	if e.format == FormatWeb3V3 {
+		return e.web3V3Output(decryptionKey, kdfName, kdfParamsMsg, iv, cipherMsg)
+	}
+	return e.eip2335Output(decryptionKey, kdfName, kdfParamsMsg, iv, cipherMsg)
+}
+
+// deriveEncryptionKey runs the configured KDF over passphrase, returning
+// the derived key along with the JSON name and params of the KDF used so
+// that callers can embed them in the keystore they produce.
+func (e *Encryptor) deriveEncryptionKey(passphrase []byte) (decryptionKey []byte, kdfName string, kdfParamsMsg map[string]interface{}, err error) {
+	switch e.kdf {
+	case KDFScrypt:
+		p := e.kdfParams.(ScryptParams)
+		salt := p.Salt
+		if len(salt) == 0 {
+			salt = make([]byte, 32)
+			if _, err = rand.Read(salt); err != nil {
+				return nil, "", nil, err
+			}
+		}
+		decryptionKey, err = scrypt.Key(passphrase, salt, p.N, p.R, p.P, p.DKLen)
+		if err != nil {
+			return nil, "", nil, err
+		}
+		return decryptionKey, "scrypt", map[string]interface{}{
+			"dklen": p.DKLen,
+			"n":     p.N,
+			"r":     p.R,
+			"p":     p.P,
+			"salt":  hex.EncodeToString(salt),
+		}, nil
+	case KDFPBKDF2:
+		p := e.kdfParams.(PBKDF2Params)
+		salt := p.Salt
+		if len(salt) == 0 {
+			salt = make([]byte, 32)
+			if _, err = rand.Read(salt); err != nil {
+				return nil, "", nil, err
+			}
+		}
+		hashFn, err := pbkdf2Hash(p.PRF)
+		if err != nil {
+			return nil, "", nil, err
+		}
+		decryptionKey = pbkdf2.Key(passphrase, salt, p.C, p.DKLen, hashFn)
+		return decryptionKey, "pbkdf2", map[string]interface{}{
+			"dklen": p.DKLen,
+			"c":     p.C,
+			"prf":   p.PRF,
+			"salt":  hex.EncodeToString(salt),
+		}, nil
+	default:
+		return nil, "", nil, fmt.Errorf("unsupported KDF")
+	}
+}
+
+func (e *Encryptor) eip2335Output(decryptionKey []byte, kdfName string, kdfParamsMsg map[string]interface{}, iv, cipherMsg []byte) (map[string]interface{}, error) {
+	h := sha256.New()
+	if _, err := h.Write(decryptionKey[16:32]); err != nil {
+		return nil, err
+	}
+	if _, err := h.Write(cipherMsg); err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"kdf": map[string]interface{}{
+			"function": kdfName,
+			"params":   kdfParamsMsg,
+			"message":  "",
+		},
+		"checksum": map[string]interface{}{
+			"function": "sha256",
+			"params":   map[string]interface{}{},
+			"message":  hex.EncodeToString(h.Sum(nil)),
+		},
+		"cipher": map[string]interface{}{
+			"function": e.cipher,
+			"params": map[string]interface{}{
+				"iv": hex.EncodeToString(iv),
+			},
+			"message": hex.EncodeToString(cipherMsg),
+		},
+	}, nil
+}
+
+func (e *Encryptor) web3V3Output(decryptionKey []byte, kdfName string, kdfParamsMsg map[string]interface{}, iv, cipherMsg []byte) (map[string]interface{}, error) {
+	// Web3 Secret Storage v3 uses Keccak256, not SHA3-256 or SHA-256, for
+	// its integrity tag.
+	h := sha3.NewLegacyKeccak256()
+	if _, err := h.Write(decryptionKey[16:32]); err != nil {
+		return nil, err
+	}
+	if _, err := h.Write(cipherMsg); err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"cipher": e.cipher,
+		"cipherparams": map[string]interface{}{
+			"iv": hex.EncodeToString(iv),
+		},
+		"ciphertext": hex.EncodeToString(cipherMsg),
+		"kdf":        kdfName,
+		"kdfparams":  kdfParamsMsg,
+		"mac":        hex.EncodeToString(h.Sum(nil)),
+	}, nil
+}
+
+func (e *Encryptor) encryptPayload(secret, decryptionKey, iv []byte) ([]byte, error) {
+	res := make([]byte, len(secret))
+	switch e.cipher {
+	case "xor":
+		for i := range secret {
+			res[i] = decryptionKey[i] ^ secret[i]
+		}
+	case "aes-128-ctr":
+		aesCipher, err := aes.NewCipher(decryptionKey[:16])
+		if err != nil {
+			return nil, err
+		}
+		stream := cipher.NewCTR(aesCipher, iv)
+		stream.XORKeyStream(res, secret)
+	case "aes-128-cbc":
+		aesCipher, err := aes.NewCipher(decryptionKey[:16])
+		if err != nil {
+			return nil, err
+		}
+		padded := pkcs7Pad(secret, aes.BlockSize)
+		res = make([]byte, len(padded))
+		cipher.NewCBCEncrypter(aesCipher, iv).CryptBlocks(res, padded)
+	default:
+		return nil, fmt.Errorf("unsupported cipher %q", e.cipher)
+	}
+
+	return res, nil
+}
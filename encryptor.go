@@ -0,0 +1,52 @@
+// Copyright © 2019 Weald Technology Trading
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+
+package keystorev4
+
+// Encryptor is an encryptor that follows the EIP-2335 keystore
+// specification, with optional support for reading and writing the
+// go-ethereum / Web3 Secret Storage v3 keystore format.
+type Encryptor struct {
+	cipher    string
+	format    Format
+	kdf       KDF
+	kdfParams interface{}
+}
+
+// New creates a new keystorev4 encryptor.
+func New(opts ...Option) *Encryptor {
+	options := options{
+		cipher:    "aes-128-ctr",
+		format:    FormatEIP2335,
+		kdf:       KDFScrypt,
+		kdfParams: ScryptStandard,
+	}
+	for _, o := range opts {
+		o.apply(&options)
+	}
+	return &Encryptor{
+		cipher:    options.cipher,
+		format:    options.format,
+		kdf:       options.kdf,
+		kdfParams: options.kdfParams,
+	}
+}
+
+// Name returns the name of this encryptor.
+func (e *Encryptor) Name() string {
+	return "scrypt"
+}
+
+// Version returns the version of this encryptor.
+func (e *Encryptor) Version() uint {
+	return 4
+}
@@ -0,0 +1,133 @@
+// Copyright © 2019 Weald Technology Trading
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+
+package keystorev4
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"errors"
+	"fmt"
+	"hash"
+)
+
+// KDF identifies the key derivation function used by Encrypt.
+type KDF int
+
+const (
+	// KDFScrypt derives the encryption key with scrypt.
+	KDFScrypt KDF = iota
+	// KDFPBKDF2 derives the encryption key with PBKDF2.
+	KDFPBKDF2
+)
+
+// pbkdf2MinIterations is the lowest PBKDF2 iteration count Encrypt will
+// accept; below this the KDF offers little protection against
+// brute-force attacks.
+const pbkdf2MinIterations = 100000
+
+// ScryptParams holds scrypt KDF parameters for Encrypt.  If Salt is
+// empty a random 32-byte salt is generated.
+type ScryptParams struct {
+	N     int
+	R     int
+	P     int
+	DKLen int
+	Salt  []byte
+}
+
+// PBKDF2Params holds PBKDF2 KDF parameters for Encrypt.  If Salt is
+// empty a random 32-byte salt is generated.
+type PBKDF2Params struct {
+	C     int
+	DKLen int
+	PRF   string
+	Salt  []byte
+}
+
+var (
+	// ScryptStandard is the scrypt parameter set recommended by
+	// EIP-2335 for interactive use.
+	ScryptStandard = ScryptParams{N: 1 << 18, R: 8, P: 1, DKLen: 32}
+
+	// ScryptLight is a lower-cost scrypt parameter set, trading
+	// brute-force resistance for latency, suitable for server-side use
+	// or other cases where interactive unlock time matters more than
+	// maximum protection against an offline attacker.
+	ScryptLight = ScryptParams{N: 1 << 12, R: 8, P: 6, DKLen: 32}
+
+	// PBKDF2Standard is the PBKDF2 parameter set recommended by
+	// EIP-2335.
+	PBKDF2Standard = PBKDF2Params{C: 262144, DKLen: 32, PRF: "hmac-sha256"}
+)
+
+// pbkdf2Hash returns the hash constructor for a PBKDF2 "prf" value, as
+// used by both the KDF and its counterpart in the Web3 Secret Storage v3
+// format. EIP-2335 only requires the prf field to be named, so there is
+// no reason to restrict it to hmac-sha256.
+func pbkdf2Hash(prf string) (func() hash.Hash, error) {
+	switch prf {
+	case "hmac-sha256":
+		return sha256.New, nil
+	case "hmac-sha384":
+		return sha512.New384, nil
+	case "hmac-sha512":
+		return sha512.New, nil
+	default:
+		return nil, fmt.Errorf("unsupported PBKDF2 PRF %q", prf)
+	}
+}
+
+// validateKDFParams checks that kdfParams is of the type expected for
+// kdf and that its values are within sane bounds.
+func validateKDFParams(kdf KDF, kdfParams interface{}) error {
+	switch kdf {
+	case KDFScrypt:
+		p, ok := kdfParams.(ScryptParams)
+		if !ok {
+			return errors.New("scrypt KDF requires ScryptParams")
+		}
+		if p.N < 2 || p.N&(p.N-1) != 0 {
+			return errors.New("scrypt N must be a power of two")
+		}
+		if p.R < 1 {
+			return errors.New("scrypt r must be at least 1")
+		}
+		if p.P < 1 {
+			return errors.New("scrypt p must be at least 1")
+		}
+		if p.DKLen < 32 {
+			return errors.New("scrypt dklen must be at least 32")
+		}
+		if len(p.Salt) > 0 && len(p.Salt) < 16 {
+			return errors.New("scrypt salt must be at least 16 bytes")
+		}
+	case KDFPBKDF2:
+		p, ok := kdfParams.(PBKDF2Params)
+		if !ok {
+			return errors.New("PBKDF2 KDF requires PBKDF2Params")
+		}
+		if p.C < pbkdf2MinIterations {
+			return fmt.Errorf("PBKDF2 iteration count must be at least %d", pbkdf2MinIterations)
+		}
+		if p.DKLen < 32 {
+			return errors.New("PBKDF2 dklen must be at least 32")
+		}
+		if len(p.Salt) > 0 && len(p.Salt) < 16 {
+			return errors.New("PBKDF2 salt must be at least 16 bytes")
+		}
+	default:
+		return errors.New("unsupported KDF")
+	}
+
+	return nil
+}
@@ -0,0 +1,91 @@
+// Copyright © 2019 Weald Technology Trading
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+
+package keystorev4
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestEncryptScryptLightRoundTrip(t *testing.T) {
+	secret := []byte("a scrypt-light secret")
+
+	e := New(WithKDF(KDFScrypt), WithKDFParams(ScryptLight))
+	ks, err := e.Encrypt(secret, "testpassword")
+	if err != nil {
+		t.Fatalf("encrypt failed: %v", err)
+	}
+
+	decrypted, err := e.Decrypt(ks, []byte("testpassword"))
+	if err != nil {
+		t.Fatalf("decrypt failed: %v", err)
+	}
+	if !bytes.Equal(decrypted, secret) {
+		t.Fatalf("decrypted secret does not match: got %q, expected %q", decrypted, secret)
+	}
+}
+
+func TestEncryptPBKDF2StandardRoundTrip(t *testing.T) {
+	secret := []byte("a pbkdf2 secret")
+
+	e := New(WithKDF(KDFPBKDF2), WithKDFParams(PBKDF2Standard))
+	ks, err := e.Encrypt(secret, "testpassword")
+	if err != nil {
+		t.Fatalf("encrypt failed: %v", err)
+	}
+
+	decrypted, err := e.Decrypt(ks, []byte("testpassword"))
+	if err != nil {
+		t.Fatalf("decrypt failed: %v", err)
+	}
+	if !bytes.Equal(decrypted, secret) {
+		t.Fatalf("decrypted secret does not match: got %q, expected %q", decrypted, secret)
+	}
+}
+
+func TestValidateKDFParamsRejectsBadScryptParams(t *testing.T) {
+	tests := []struct {
+		name   string
+		params ScryptParams
+	}{
+		{name: "N not a power of two", params: ScryptParams{N: 100, R: 8, P: 1, DKLen: 32}},
+		{name: "r below 1", params: ScryptParams{N: 1 << 12, R: 0, P: 1, DKLen: 32}},
+		{name: "p below 1", params: ScryptParams{N: 1 << 12, R: 8, P: 0, DKLen: 32}},
+		{name: "dklen below 32", params: ScryptParams{N: 1 << 12, R: 8, P: 1, DKLen: 16}},
+		{name: "salt shorter than 16 bytes", params: ScryptParams{N: 1 << 12, R: 8, P: 1, DKLen: 32, Salt: make([]byte, 8)}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := validateKDFParams(KDFScrypt, test.params); err == nil {
+				t.Fatal("expected invalid scrypt params to be rejected")
+			}
+		})
+	}
+}
+
+func TestValidateKDFParamsRejectsLowPBKDF2Iterations(t *testing.T) {
+	params := PBKDF2Params{C: 1, DKLen: 32, PRF: "hmac-sha256"}
+	if err := validateKDFParams(KDFPBKDF2, params); err == nil {
+		t.Fatal("expected a PBKDF2 iteration count below the floor to be rejected")
+	}
+}
+
+func TestValidateKDFParamsRejectsWrongParamsType(t *testing.T) {
+	if err := validateKDFParams(KDFScrypt, PBKDF2Standard); err == nil {
+		t.Fatal("expected scrypt KDF with PBKDF2Params to be rejected")
+	}
+	if err := validateKDFParams(KDFPBKDF2, ScryptStandard); err == nil {
+		t.Fatal("expected PBKDF2 KDF with ScryptParams to be rejected")
+	}
+}
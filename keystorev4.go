@@ -0,0 +1,87 @@
+// Copyright © 2019 Weald Technology Trading
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+
+package keystorev4
+
+// keystoreV4 is the "crypto" envelope of an EIP-2335 keystore.
+type keystoreV4 struct {
+	Checksum *checksumModule `json:"checksum"`
+	Cipher   *cipherModule   `json:"cipher"`
+	KDF      *kdfModule      `json:"kdf"`
+}
+
+type checksumModule struct {
+	Function string `json:"function"`
+	Message  string `json:"message"`
+}
+
+type cipherModule struct {
+	Function string       `json:"function"`
+	Params   cipherParams `json:"params"`
+	Message  string       `json:"message"`
+}
+
+type cipherParams struct {
+	IV string `json:"iv"`
+}
+
+type kdfModule struct {
+	Function string    `json:"function"`
+	Params   kdfParams `json:"params"`
+	Message  string    `json:"message"`
+}
+
+type kdfParams struct {
+	DKLen int    `json:"dklen"`
+	N     int    `json:"n"`
+	R     int    `json:"r"`
+	P     int    `json:"p"`
+	Salt  string `json:"salt"`
+	C     int    `json:"c"`
+	PRF   string `json:"prf"`
+}
+
+// web3V3Keystore is the "crypto" envelope of a go-ethereum / Web3 Secret
+// Storage v3 keystore, as produced by geth and most Ethereum
+// execution-layer wallets.
+type web3V3Keystore struct {
+	Cipher       string             `json:"cipher"`
+	CipherParams web3V3CipherParams `json:"cipherparams"`
+	Ciphertext   string             `json:"ciphertext"`
+	KDF          string             `json:"kdf"`
+	KDFParams    web3V3KDFParams    `json:"kdfparams"`
+	MAC          string             `json:"mac"`
+}
+
+type web3V3CipherParams struct {
+	IV string `json:"iv"`
+}
+
+type web3V3KDFParams struct {
+	DKLen int    `json:"dklen"`
+	N     int    `json:"n"`
+	R     int    `json:"r"`
+	P     int    `json:"p"`
+	C     int    `json:"c"`
+	PRF   string `json:"prf"`
+	Salt  string `json:"salt"`
+}
+
+// isWeb3V3 returns true if data looks like the "crypto" envelope of a
+// go-ethereum / Web3 Secret Storage v3 keystore rather than an EIP-2335
+// one: it carries "mac" and "ciphertext" fields instead of the
+// EIP-2335 "checksum" and "cipher.message" fields.
+func isWeb3V3(data map[string]interface{}) bool {
+	_, hasMAC := data["mac"]
+	_, hasCiphertext := data["ciphertext"]
+	return hasMAC && hasCiphertext
+}
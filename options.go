@@ -0,0 +1,76 @@
+// Copyright © 2019 Weald Technology Trading
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+
+package keystorev4
+
+// Format is the on-disk keystore format that the encryptor reads and
+// writes.
+type Format int
+
+const (
+	// FormatEIP2335 is the standard eth2 EIP-2335 keystore format.
+	FormatEIP2335 Format = iota
+	// FormatWeb3V3 is the go-ethereum / Web3 Secret Storage v3 keystore
+	// format used by Ethereum execution-layer wallets.
+	FormatWeb3V3
+)
+
+type options struct {
+	cipher    string
+	format    Format
+	kdf       KDF
+	kdfParams interface{}
+}
+
+// Option gives options for the encryptor.
+type Option interface {
+	apply(*options)
+}
+
+type optionFunc func(*options)
+
+func (f optionFunc) apply(o *options) {
+	f(o)
+}
+
+// WithCipher sets the cipher for the encryptor.
+func WithCipher(cipher string) Option {
+	return optionFunc(func(o *options) {
+		o.cipher = cipher
+	})
+}
+
+// WithFormat sets the keystore format used by Encrypt.  Decrypt does not
+// need this option: it detects EIP-2335 and Web3 Secret Storage v3
+// keystores automatically from their shape.
+func WithFormat(format Format) Option {
+	return optionFunc(func(o *options) {
+		o.format = format
+	})
+}
+
+// WithKDF sets the key derivation function used by Encrypt.
+func WithKDF(kdf KDF) Option {
+	return optionFunc(func(o *options) {
+		o.kdf = kdf
+	})
+}
+
+// WithKDFParams sets the key derivation function parameters used by
+// Encrypt.  It takes a ScryptParams when the KDF is KDFScrypt (e.g.
+// ScryptStandard or ScryptLight), or a PBKDF2Params when the KDF is
+// KDFPBKDF2 (e.g. PBKDF2Standard).
+func WithKDFParams(kdfParams interface{}) Option {
+	return optionFunc(func(o *options) {
+		o.kdfParams = kdfParams
+	})
+}
@@ -0,0 +1,50 @@
+// Copyright © 2019 Weald Technology Trading
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+
+package keystorev4
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestEncryptPBKDF2PRFRoundTrip(t *testing.T) {
+	tests := []string{"hmac-sha256", "hmac-sha384", "hmac-sha512"}
+
+	for _, prf := range tests {
+		t.Run(prf, func(t *testing.T) {
+			secret := []byte("a pbkdf2 prf secret")
+			params := PBKDF2Standard
+			params.PRF = prf
+
+			e := New(WithKDF(KDFPBKDF2), WithKDFParams(params))
+			ks, err := e.Encrypt(secret, "testpassword")
+			if err != nil {
+				t.Fatalf("encrypt failed: %v", err)
+			}
+
+			decrypted, err := e.Decrypt(ks, []byte("testpassword"))
+			if err != nil {
+				t.Fatalf("decrypt failed: %v", err)
+			}
+			if !bytes.Equal(decrypted, secret) {
+				t.Fatalf("decrypted secret does not match: got %q, expected %q", decrypted, secret)
+			}
+		})
+	}
+}
+
+func TestPBKDF2HashRejectsUnsupportedPRF(t *testing.T) {
+	if _, err := pbkdf2Hash("hmac-sha1"); err == nil {
+		t.Fatal("expected an unsupported PBKDF2 PRF to be rejected")
+	}
+}
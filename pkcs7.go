@@ -0,0 +1,48 @@
+// Copyright © 2019 Weald Technology Trading
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+
+package keystorev4
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"errors"
+)
+
+// pkcs7Pad pads data to a multiple of blockSize per PKCS#7.
+func pkcs7Pad(data []byte, blockSize int) []byte {
+	padLen := blockSize - len(data)%blockSize
+	padding := bytes.Repeat([]byte{byte(padLen)}, padLen)
+	return append(data, padding...)
+}
+
+// pkcs7Unpad strips PKCS#7 padding from data, rejecting malformed
+// padding.  The padding bytes are checked with a constant-time
+// comparison, so the time taken does not depend on which of them is
+// wrong; it does depend on the padding length given by the last byte.
+func pkcs7Unpad(data []byte, blockSize int) ([]byte, error) {
+	if len(data) == 0 || len(data)%blockSize != 0 {
+		return nil, errors.New("invalid padded data")
+	}
+
+	padLen := int(data[len(data)-1])
+	if padLen == 0 || padLen > blockSize {
+		return nil, errors.New("invalid padding")
+	}
+
+	expected := bytes.Repeat([]byte{byte(padLen)}, padLen)
+	if subtle.ConstantTimeCompare(data[len(data)-padLen:], expected) != 1 {
+		return nil, errors.New("invalid padding")
+	}
+
+	return data[:len(data)-padLen], nil
+}
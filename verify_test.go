@@ -0,0 +1,74 @@
+// Copyright © 2019 Weald Technology Trading
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+
+package keystorev4
+
+import (
+	"encoding/hex"
+	"testing"
+)
+
+func TestVerifyCorrectPassphrase(t *testing.T) {
+	e := New()
+	ks, err := e.Encrypt([]byte("a secret"), "testpassword")
+	if err != nil {
+		t.Fatalf("encrypt failed: %v", err)
+	}
+
+	if err := e.Verify(ks, []byte("testpassword")); err != nil {
+		t.Fatalf("verify failed: %v", err)
+	}
+}
+
+func TestVerifyWrongPassphrase(t *testing.T) {
+	e := New()
+	ks, err := e.Encrypt([]byte("a secret"), "testpassword")
+	if err != nil {
+		t.Fatalf("encrypt failed: %v", err)
+	}
+
+	if err := e.Verify(ks, []byte("wrongpassword")); err == nil {
+		t.Fatal("expected verify with wrong passphrase to fail")
+	}
+}
+
+func TestVerifyTamperedChecksum(t *testing.T) {
+	e := New()
+	ks, err := e.Encrypt([]byte("a secret"), "testpassword")
+	if err != nil {
+		t.Fatalf("encrypt failed: %v", err)
+	}
+
+	checksum := ks["checksum"].(map[string]interface{})
+	msg, err := hex.DecodeString(checksum["message"].(string))
+	if err != nil {
+		t.Fatalf("failed to decode checksum: %v", err)
+	}
+	msg[0] ^= 0xff
+	checksum["message"] = hex.EncodeToString(msg)
+
+	if err := e.Verify(ks, []byte("testpassword")); err == nil {
+		t.Fatal("expected verify with a tampered checksum to fail")
+	}
+}
+
+func TestVerifyDoesNotSupportWeb3V3(t *testing.T) {
+	e := New(WithFormat(FormatWeb3V3))
+	ks, err := e.Encrypt([]byte("a secret"), "testpassword")
+	if err != nil {
+		t.Fatalf("encrypt failed: %v", err)
+	}
+
+	if err := e.Verify(ks, []byte("testpassword")); err == nil {
+		t.Fatal("expected verify of a Web3 Secret Storage v3 keystore to fail")
+	}
+}
@@ -0,0 +1,97 @@
+// Copyright © 2019 Weald Technology Trading
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+
+package keystorev4
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+func TestWeb3V3RoundTrip(t *testing.T) {
+	secret := make([]byte, 32)
+	for i := range secret {
+		secret[i] = byte(i)
+	}
+
+	e := New(WithFormat(FormatWeb3V3))
+	ks, err := e.Encrypt(secret, "testpassword")
+	if err != nil {
+		t.Fatalf("encrypt failed: %v", err)
+	}
+	if !isWeb3V3(ks) {
+		t.Fatal("encrypted keystore does not look like a Web3 Secret Storage v3 crypto envelope")
+	}
+
+	decrypted, err := e.Decrypt(ks, []byte("testpassword"))
+	if err != nil {
+		t.Fatalf("decrypt failed: %v", err)
+	}
+	if !bytes.Equal(decrypted, secret) {
+		t.Fatalf("decrypted secret does not match: got %x, expected %x", decrypted, secret)
+	}
+}
+
+func TestWeb3V3WrongPassphrase(t *testing.T) {
+	secret := make([]byte, 32)
+	e := New(WithFormat(FormatWeb3V3))
+	ks, err := e.Encrypt(secret, "testpassword")
+	if err != nil {
+		t.Fatalf("encrypt failed: %v", err)
+	}
+
+	if _, err := e.Decrypt(ks, []byte("wrongpassword")); err == nil {
+		t.Fatal("expected decrypt with wrong passphrase to fail")
+	}
+}
+
+func TestWeb3V3TamperedMAC(t *testing.T) {
+	secret := make([]byte, 32)
+	e := New(WithFormat(FormatWeb3V3))
+	ks, err := e.Encrypt(secret, "testpassword")
+	if err != nil {
+		t.Fatalf("encrypt failed: %v", err)
+	}
+
+	mac, err := hex.DecodeString(ks["mac"].(string))
+	if err != nil {
+		t.Fatalf("failed to decode mac: %v", err)
+	}
+	mac[0] ^= 0xff
+	ks["mac"] = hex.EncodeToString(mac)
+
+	if _, err := e.Decrypt(ks, []byte("testpassword")); err == nil {
+		t.Fatal("expected decrypt with tampered mac to fail")
+	}
+}
+
+func TestWeb3V3UnsupportedKDF(t *testing.T) {
+	ks := map[string]interface{}{
+		"cipher": "aes-128-ctr",
+		"cipherparams": map[string]interface{}{
+			"iv": "00000000000000000000000000000000",
+		},
+		"ciphertext": "00",
+		"kdf":        "bcrypt",
+		"kdfparams": map[string]interface{}{
+			"dklen": 32,
+			"salt":  "00",
+		},
+		"mac": "00",
+	}
+
+	e := New(WithFormat(FormatWeb3V3))
+	if _, err := e.Decrypt(ks, []byte("testpassword")); err == nil {
+		t.Fatal("expected decrypt with an unsupported KDF to fail")
+	}
+}